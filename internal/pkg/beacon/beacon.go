@@ -0,0 +1,51 @@
+// Package beacon provides access to an external randomness beacon (drand),
+// used to seed ticket VRFs and Election PoSt challenges with randomness that
+// no miner can bias or predict ahead of time.
+package beacon
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// BeaconEntry is one round of output from a randomness beacon.
+type BeaconEntry struct {
+	// Round is the beacon's monotonically increasing round number.
+	Round uint64
+	// Data is this round's randomness, verifiable against the beacon
+	// group's distributed public key.
+	Data []byte
+	// PrevSig is the signature of the previous round, chaining this entry
+	// to the one before it.
+	PrevSig []byte
+}
+
+// RandomnessSource supplies beacon entries by epoch, so miners and
+// validators can derive the randomness a given epoch should use without
+// depending on any one miner's tickets.
+type RandomnessSource interface {
+	// EntryAt returns the beacon entry in effect at epoch. When the beacon
+	// cannot be reached, an implementation may return the most recent entry
+	// it has instead, as DrandBeacon does.
+	EntryAt(ctx context.Context, epoch uint64) (BeaconEntry, error)
+}
+
+// ValidateEntryChain checks that entries, the beacon entries a block
+// attaches, form a single unbroken chain: rounds strictly increasing and
+// each entry's PrevSig matching the Data of the entry before it. It does not
+// re-verify any entry's signature against the group public key; that already
+// happened when the entry was fetched through a RandomnessSource.
+func ValidateEntryChain(entries []BeaconEntry) error {
+	for i := 1; i < len(entries); i++ {
+		prev, cur := entries[i-1], entries[i]
+		if cur.Round <= prev.Round {
+			return errors.Errorf("beacon entry round %d does not strictly increase from %d", cur.Round, prev.Round)
+		}
+		if !bytes.Equal(cur.PrevSig, prev.Data) {
+			return errors.Errorf("beacon entry round %d does not chain to round %d", cur.Round, prev.Round)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package beacon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestValidateEntryChainAcceptsUnbrokenChain(t *testing.T) {
+	tf.UnitTest(t)
+
+	entries := []BeaconEntry{
+		{Round: 5, Data: []byte("sig-5")},
+		{Round: 6, Data: []byte("sig-6"), PrevSig: []byte("sig-5")},
+		{Round: 7, Data: []byte("sig-7"), PrevSig: []byte("sig-6")},
+	}
+
+	assert.NoError(t, ValidateEntryChain(entries))
+}
+
+func TestValidateEntryChainRejectsNonIncreasingRound(t *testing.T) {
+	tf.UnitTest(t)
+
+	entries := []BeaconEntry{
+		{Round: 5, Data: []byte("sig-5")},
+		{Round: 5, Data: []byte("sig-5-again"), PrevSig: []byte("sig-5")},
+	}
+
+	assert.Error(t, ValidateEntryChain(entries))
+}
+
+func TestValidateEntryChainRejectsBrokenLink(t *testing.T) {
+	tf.UnitTest(t)
+
+	entries := []BeaconEntry{
+		{Round: 5, Data: []byte("sig-5")},
+		{Round: 6, Data: []byte("sig-6"), PrevSig: []byte("not-sig-5")},
+	}
+
+	assert.Error(t, ValidateEntryChain(entries))
+}
+
+func TestValidateEntryChainEmptyAndSingleAreValid(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert.NoError(t, ValidateEntryChain(nil))
+	assert.NoError(t, ValidateEntryChain([]BeaconEntry{{Round: 1, Data: []byte("sig-1")}}))
+}
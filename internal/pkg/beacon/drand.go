@@ -0,0 +1,175 @@
+package beacon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	bls "github.com/filecoin-project/go-bls-sigs"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+)
+
+var log = logging.Logger("beacon")
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Group describes a drand group: the nodes to query for entries and the
+// group's distributed public key, which every entry's BLS signature is
+// verified against.
+type Group struct {
+	Nodes     []string
+	PublicKey []byte
+}
+
+// Client fetches a single round from a drand group over HTTP or gRPC.
+type Client interface {
+	Get(ctx context.Context, round uint64) (BeaconEntry, error)
+}
+
+// DrandBeacon is a RandomnessSource backed by a drand group. It caches
+// entries it has already fetched and verified, and when the group is
+// unreachable it falls back to the last known-good entry with exponential
+// backoff rather than blocking block production indefinitely.
+type DrandBeacon struct {
+	client       Client
+	group        Group
+	interval     time.Duration
+	genesisEpoch uint64
+
+	mu       sync.Mutex
+	cache    map[uint64]BeaconEntry
+	lastGood BeaconEntry
+	haveLast bool
+	backoff  time.Duration
+}
+
+// NewDrandBeacon returns a RandomnessSource pulling one entry every interval
+// from group via client, with round 0 falling at genesisEpoch.
+func NewDrandBeacon(client Client, group Group, interval time.Duration, genesisEpoch uint64) *DrandBeacon {
+	return &DrandBeacon{
+		client:       client,
+		group:        group,
+		interval:     interval,
+		genesisEpoch: genesisEpoch,
+		cache:        map[uint64]BeaconEntry{},
+		backoff:      minBackoff,
+	}
+}
+
+// EntryAt returns the beacon entry in effect at epoch, fetching and
+// verifying it against the group's public key if it isn't already cached.
+func (b *DrandBeacon) EntryAt(ctx context.Context, epoch uint64) (BeaconEntry, error) {
+	round := b.roundAt(epoch)
+
+	b.mu.Lock()
+	if entry, ok := b.cache[round]; ok {
+		b.mu.Unlock()
+		return entry, nil
+	}
+	b.mu.Unlock()
+
+	entry, err := b.client.Get(ctx, round)
+	if err != nil {
+		return b.fallback(err)
+	}
+	if err := verifyEntry(entry, b.group.PublicKey); err != nil {
+		return BeaconEntry{}, errors.Wrap(err, "invalid drand entry")
+	}
+
+	b.mu.Lock()
+	b.cache[round] = entry
+	b.lastGood = entry
+	b.haveLast = true
+	b.backoff = minBackoff
+	b.mu.Unlock()
+
+	return entry, nil
+}
+
+func (b *DrandBeacon) roundAt(epoch uint64) uint64 {
+	if epoch < b.genesisEpoch {
+		return 0
+	}
+	intervalEpochs := uint64(b.interval.Seconds())
+	if intervalEpochs == 0 {
+		intervalEpochs = 1
+	}
+	return (epoch - b.genesisEpoch) / intervalEpochs
+}
+
+// fallback reports the last known-good entry instead of blocking on drand
+// availability, backing off exponentially so repeated failures don't
+// hammer a down group.
+func (b *DrandBeacon) fallback(cause error) (BeaconEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.haveLast {
+		return BeaconEntry{}, errors.Wrap(cause, "drand unavailable and no prior entry to fall back to")
+	}
+
+	log.Warnf("drand unreachable (%s), falling back to round %d and backing off %s", cause, b.lastGood.Round, b.backoff)
+	time.Sleep(b.backoff)
+	b.backoff *= 2
+	if b.backoff > maxBackoff {
+		b.backoff = maxBackoff
+	}
+	return b.lastGood, nil
+}
+
+// verifyEntry checks entry.Data is a valid BLS signature, by the group
+// holding groupPublicKey, over entry.PrevSig and entry.Round.
+func verifyEntry(entry BeaconEntry, groupPublicKey []byte) error {
+	if len(entry.Data) == 0 {
+		return errors.New("empty beacon entry")
+	}
+
+	var sig bls.Signature
+	if len(entry.Data) != len(sig) {
+		return errors.Errorf("beacon entry signature has wrong length: got %d, want %d", len(entry.Data), len(sig))
+	}
+	copy(sig[:], entry.Data)
+
+	var pubKey bls.PublicKey
+	if len(groupPublicKey) != len(pubKey) {
+		return errors.Errorf("beacon group public key has wrong length: got %d, want %d", len(groupPublicKey), len(pubKey))
+	}
+	copy(pubKey[:], groupPublicKey)
+
+	digest := bls.HashMessage(entrySignedMessage(entry))
+	if !bls.Verify(&sig, []bls.Digest{digest}, []bls.PublicKey{pubKey}) {
+		return errors.New("beacon entry signature does not verify against group public key")
+	}
+	return nil
+}
+
+// entrySignedMessage reconstructs the message a drand group member signs to
+// produce an entry: the previous round's signature chained with this
+// round's number.
+func entrySignedMessage(entry BeaconEntry) bls.Message {
+	msg := make([]byte, len(entry.PrevSig)+8)
+	n := copy(msg, entry.PrevSig)
+	binary.BigEndian.PutUint64(msg[n:], entry.Round)
+	return msg
+}
+
+// Seed derives the randomness a miner should use for both the ticket VRF
+// and the Election PoSt challenge at epoch, from the most recent beacon
+// entry: H(entry.Data || minerAddr || epoch).
+func Seed(entry BeaconEntry, minerAddr address.Address, epoch uint64) []byte {
+	h := sha256.New()
+	h.Write(entry.Data)
+	h.Write(minerAddr.Bytes())
+	epochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(epochBytes, epoch)
+	h.Write(epochBytes)
+	return h.Sum(nil)
+}
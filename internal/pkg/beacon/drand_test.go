@@ -0,0 +1,141 @@
+package beacon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bls "github.com/filecoin-project/go-bls-sigs"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+// signEntry builds the BeaconEntry a drand group member signing with sk
+// would produce for round, chained to prevSig.
+func signEntry(sk bls.PrivateKey, round uint64, prevSig []byte) BeaconEntry {
+	entry := BeaconEntry{Round: round, PrevSig: prevSig}
+	sig := bls.PrivateKeySign(sk, entrySignedMessage(entry))
+	entry.Data = sig[:]
+	return entry
+}
+
+func TestVerifyEntryAcceptsValidSignature(t *testing.T) {
+	tf.UnitTest(t)
+
+	sk := bls.PrivateKeyGenerate()
+	pk := sk.PublicKey()
+	entry := signEntry(sk, 7, []byte("prev-round-signature"))
+
+	assert.NoError(t, verifyEntry(entry, pk[:]))
+}
+
+func TestVerifyEntryRejectsWrongKey(t *testing.T) {
+	tf.UnitTest(t)
+
+	sk := bls.PrivateKeyGenerate()
+	other := bls.PrivateKeyGenerate()
+	entry := signEntry(sk, 7, []byte("prev-round-signature"))
+	otherPk := other.PublicKey()
+
+	assert.Error(t, verifyEntry(entry, otherPk[:]))
+}
+
+func TestVerifyEntryRejectsEmptyData(t *testing.T) {
+	tf.UnitTest(t)
+
+	sk := bls.PrivateKeyGenerate()
+	pk := sk.PublicKey()
+
+	assert.Error(t, verifyEntry(BeaconEntry{Round: 1}, pk[:]))
+}
+
+// fakeClient returns a canned entry per round, or a canned error, so
+// DrandBeacon's caching and fallback logic can be tested without a real
+// drand group.
+type fakeClient struct {
+	entries map[uint64]BeaconEntry
+	err     error
+	calls   int
+}
+
+func (c *fakeClient) Get(ctx context.Context, round uint64) (BeaconEntry, error) {
+	c.calls++
+	if c.err != nil {
+		return BeaconEntry{}, c.err
+	}
+	entry, ok := c.entries[round]
+	if !ok {
+		return BeaconEntry{}, errors.Errorf("no entry for round %d", round)
+	}
+	return entry, nil
+}
+
+func TestDrandBeaconEntryAtCachesVerifiedEntries(t *testing.T) {
+	tf.UnitTest(t)
+
+	sk := bls.PrivateKeyGenerate()
+	pk := sk.PublicKey()
+	entry := signEntry(sk, 1, nil)
+
+	client := &fakeClient{entries: map[uint64]BeaconEntry{1: entry}}
+	b := NewDrandBeacon(client, Group{PublicKey: pk[:]}, time.Second, 0)
+
+	got, err := b.EntryAt(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, entry.Round, got.Round)
+
+	_, err = b.EntryAt(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, client.calls, "second call should be served from cache, not hit the client again")
+}
+
+func TestDrandBeaconEntryAtRejectsBadSignature(t *testing.T) {
+	tf.UnitTest(t)
+
+	sk := bls.PrivateKeyGenerate()
+	other := bls.PrivateKeyGenerate()
+	entry := signEntry(sk, 1, nil)
+	otherPk := other.PublicKey()
+
+	client := &fakeClient{entries: map[uint64]BeaconEntry{1: entry}}
+	b := NewDrandBeacon(client, Group{PublicKey: otherPk[:]}, time.Second, 0)
+
+	_, err := b.EntryAt(context.Background(), 1)
+	assert.Error(t, err)
+}
+
+func TestDrandBeaconFallsBackToLastGoodOnError(t *testing.T) {
+	tf.UnitTest(t)
+
+	sk := bls.PrivateKeyGenerate()
+	pk := sk.PublicKey()
+	entry := signEntry(sk, 1, nil)
+
+	client := &fakeClient{entries: map[uint64]BeaconEntry{1: entry}}
+	b := NewDrandBeacon(client, Group{PublicKey: pk[:]}, time.Second, 0)
+	b.backoff = time.Millisecond // keep the test fast
+
+	_, err := b.EntryAt(context.Background(), 1)
+	require.NoError(t, err)
+
+	client.err = errors.New("drand group unreachable")
+	client.entries = nil
+
+	got, err := b.EntryAt(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, entry.Round, got.Round, "should fall back to the last verified entry")
+}
+
+func TestDrandBeaconNoPriorEntryToFallBackToIsAnError(t *testing.T) {
+	tf.UnitTest(t)
+
+	client := &fakeClient{err: errors.New("drand group unreachable")}
+	b := NewDrandBeacon(client, Group{}, time.Second, 0)
+	b.backoff = time.Millisecond
+
+	_, err := b.EntryAt(context.Background(), 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,183 @@
+package block
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/beacon"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// Ticket is the output of a miner's VRF over the previous ticket, used both
+// to elect the next block producer and, until the drand beacon was wired
+// in, to seed the Election PoSt challenge.
+type Ticket struct {
+	// VRFProof is the VRF output over the parent tipset's min ticket.
+	VRFProof []byte
+}
+
+// VRFPi is a VRF proof output, returned by Consensus.CheckWinner as the
+// evidence backing a ticket's win.
+type VRFPi []byte
+
+// TipSetKey is the canonical, comparable identifier of a TipSet: the CIDs
+// of its blocks in sorted order, joined so two TipSetKeys built from the
+// same block set always compare equal.
+type TipSetKey struct {
+	key string
+}
+
+// NewTipSetKey returns the TipSetKey for cids, independent of the order
+// they're given in.
+func NewTipSetKey(cids ...cid.Cid) TipSetKey {
+	strs := make([]string, len(cids))
+	for i, c := range cids {
+		strs[i] = c.String()
+	}
+	sort.Strings(strs)
+	return TipSetKey{key: strings.Join(strs, ",")}
+}
+
+// String returns tsk's canonical string form.
+func (tsk TipSetKey) String() string {
+	return tsk.key
+}
+
+// Block is a single block in the chain. A block is produced by one miner at
+// one height, extending one or more parent blocks (its Parents), and
+// carries every input a validator needs to check it was legitimately
+// produced: the tickets and Election PoSt proof backing its win, the drand
+// entries its randomness was drawn from, and the signature over all of it.
+type Block struct {
+	// Miner is the actor address credited with this block's reward.
+	Miner address.Address
+	// Height is this block's epoch.
+	Height types.Uint64
+	// Messages is the root of the secp/BLS message lists this block applied.
+	Messages types.TxMeta
+	// MessageReceipts is the root of the receipts produced applying
+	// Messages.
+	MessageReceipts cid.Cid
+	// Parents is the key of the tipset this block extends.
+	Parents TipSetKey
+	// ParentWeight is the chain weight of Parents.
+	ParentWeight types.Uint64
+	// StateRoot is the state tree root after applying Messages.
+	StateRoot cid.Cid
+	// Timestamp is the wall-clock time this block claims to have been
+	// produced at.
+	Timestamp types.Uint64
+	// Tickets are this block's VRF tickets, most recent last.
+	Tickets []Ticket
+	// EPoStInfo is the Election PoSt proof backing this block's win.
+	EPoStInfo EPostProof
+	// BeaconEntries are every drand entry after the parent epoch up to and
+	// including Height, deduplicated across null blocks.
+	BeaconEntries []beacon.BeaconEntry
+	// BLSAggregateSig aggregates the signatures of every BLS message in
+	// Messages.
+	BLSAggregateSig types.Signature
+	// BlockSig is the worker's signature over SignatureData().
+	BlockSig types.Signature
+}
+
+func init() {
+	cbor.RegisterCborType(Block{})
+	cbor.RegisterCborType(Ticket{})
+	cbor.RegisterCborType(EPostProof{})
+	cbor.RegisterCborType(EPostTicket{})
+	cbor.RegisterCborType(beacon.BeaconEntry{})
+}
+
+// SignatureData returns the bytes BlockSig is computed over: every field of
+// the block except BlockSig itself, so the signature can't be used to
+// authenticate a different signature value.
+func (b *Block) SignatureData() []byte {
+	unsigned := *b
+	unsigned.BlockSig = types.Signature{}
+
+	raw, err := cbor.DumpObject(&unsigned)
+	if err != nil {
+		// Block is registered with cbor.RegisterCborType above and contains
+		// nothing but the types that registration already handles
+		// elsewhere in the codebase, so encoding it can't fail.
+		panic(errors.Wrap(err, "encode block for signing"))
+	}
+	return raw
+}
+
+// TipSet is a set of blocks at the same height with the same parents,
+// competing to extend the same chain. Blocks are kept sorted by ticket so
+// every caller observes the same ordering, in particular MinTicket.
+type TipSet struct {
+	blocks []*Block
+}
+
+// NewTipSet returns the TipSet formed by blocks, which must be non-empty
+// and share a height and parent set.
+func NewTipSet(blocks ...*Block) (TipSet, error) {
+	if len(blocks) == 0 {
+		return TipSet{}, errors.New("tipset must have at least one block")
+	}
+	sorted := append([]*Block{}, blocks...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return ticketLess(sorted[i].Tickets, sorted[j].Tickets)
+	})
+	return TipSet{blocks: sorted}, nil
+}
+
+func ticketLess(a, b []Ticket) bool {
+	aBytes, bBytes := []byte(nil), []byte(nil)
+	if len(a) > 0 {
+		aBytes = a[len(a)-1].VRFProof
+	}
+	if len(b) > 0 {
+		bBytes = b[len(b)-1].VRFProof
+	}
+	return string(aBytes) < string(bBytes)
+}
+
+// Height returns the epoch shared by every block in ts.
+func (ts TipSet) Height() (uint64, error) {
+	if len(ts.blocks) == 0 {
+		return 0, errors.New("empty tipset has no height")
+	}
+	return uint64(ts.blocks[0].Height), nil
+}
+
+// Key returns the TipSetKey identifying ts.
+func (ts TipSet) Key() TipSetKey {
+	cids := make([]cid.Cid, 0, len(ts.blocks))
+	for _, blk := range ts.blocks {
+		nd, err := cbor.WrapObject(blk, mh.SHA2_256, -1)
+		if err != nil {
+			continue
+		}
+		cids = append(cids, nd.Cid())
+	}
+	return NewTipSetKey(cids...)
+}
+
+// MinTicket returns the lowest ticket among ts's blocks: the ticket every
+// block extending ts derives its own VRF input from.
+func (ts TipSet) MinTicket() (Ticket, error) {
+	if len(ts.blocks) == 0 {
+		return Ticket{}, errors.New("empty tipset has no min ticket")
+	}
+	blk := ts.blocks[0]
+	if len(blk.Tickets) == 0 {
+		return Ticket{}, errors.New("tipset's lowest block has no ticket")
+	}
+	return blk.Tickets[len(blk.Tickets)-1], nil
+}
+
+// String returns a human-readable identifier for ts, suitable for logging.
+func (ts TipSet) String() string {
+	return ts.Key().String()
+}
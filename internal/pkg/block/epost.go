@@ -0,0 +1,62 @@
+package block
+
+import (
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// EPostProof is the Election PoSt proof a miner attaches to a block to show
+// it won the right to produce it: a SNARK proof over the candidate partial
+// tickets, the randomness the candidates were computed against, and the
+// candidates themselves.
+type EPostProof struct {
+	// PoStProof is the serialized SNARK proof that every candidate's Partial
+	// ticket was computed correctly against PostRand.
+	PoStProof []byte
+	// PostRand is the randomness the election PoSt challenge was seeded
+	// with.
+	PostRand []byte
+	// Candidates are the partial tickets generated for this election; every
+	// one must beat the per-sector winning threshold.
+	Candidates []EPostTicket
+}
+
+// EPostTicket is a single partial ticket generated by the Election PoSt
+// over one of a miner's sectors.
+type EPostTicket struct {
+	// Partial is the partial ticket value itself.
+	Partial []byte
+	// SectorID identifies the sector this candidate was generated from.
+	SectorID uint64
+	// ChallengeIndex is the index of the PoSt challenge this candidate
+	// answers.
+	ChallengeIndex uint64
+}
+
+// EPoStWinThreshold returns the winning threshold, out of the full 2^256
+// ticket space, for a miner holding minerPower out of totalPower: a partial
+// ticket wins an Election PoSt round only if its value is strictly below it.
+func EPoStWinThreshold(minerPower, totalPower uint64) *big.Int {
+	if totalPower == 0 {
+		return big.NewInt(0)
+	}
+	maxTicket := new(big.Int).Lsh(big.NewInt(1), 256)
+	threshold := maxTicket.Mul(maxTicket, new(big.Int).SetUint64(minerPower))
+	return threshold.Div(threshold, new(big.Int).SetUint64(totalPower))
+}
+
+// ValidateCandidates reports whether every candidate in p beats the winning
+// threshold for a miner holding minerPower out of totalPower. It only checks
+// the ticket arithmetic; the caller is still responsible for verifying the
+// PoStProof SNARK and for re-deriving PostRand from chain state.
+func (p EPostProof) ValidateCandidates(minerPower, totalPower uint64) error {
+	threshold := EPoStWinThreshold(minerPower, totalPower)
+	for i, c := range p.Candidates {
+		ticketVal := new(big.Int).SetBytes(c.Partial)
+		if ticketVal.Cmp(threshold) >= 0 {
+			return errors.Errorf("candidate %d (sector %d) does not beat the election threshold", i, c.SectorID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	car "github.com/ipfs/go-car"
+	"github.com/ipfs/go-cid"
+	datastore "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+// StateLoader loads the state tree rooted at root out of store, so a
+// vector's preconditions can be applied against it. It is injected rather
+// than implemented here because building a vm.StateTree from a CID is the
+// chain package's job, not this one's.
+type StateLoader interface {
+	LoadStateTree(ctx context.Context, store vm.Blockstore, root cid.Cid) (vm.StateTree, error)
+}
+
+// MessageApplier applies a vector's messages against a state tree and pays
+// out the block reward, mirroring the interface DefaultWorker's Consensus
+// implementations apply messages through.
+type MessageApplier interface {
+	ApplyMessagesAndPayRewards(ctx context.Context, st vm.StateTree, vms vm.StorageMap, messages []*types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, ancestors []block.TipSet, rngSeed []byte) (*vm.ApplyMessagesResponse, error)
+}
+
+// ReceiptStore computes the root CID a vector's receipts would be persisted
+// under, so a vector can check it without a miner's real message store
+// wired up.
+type ReceiptStore interface {
+	StoreReceipts(ctx context.Context, receipts []*types.MessageReceipt) (cid.Cid, error)
+}
+
+// Driver replays Vectors against an injected StateLoader, MessageApplier,
+// and ReceiptStore, so the same vector can be run against this
+// implementation's real message-application path or, with different fakes
+// wired in, against a stand-in used purely to shake out the harness itself.
+type Driver struct {
+	stateLoader  StateLoader
+	applier      MessageApplier
+	receiptStore ReceiptStore
+}
+
+// NewDriver returns a Driver that replays vectors using stateLoader to load
+// precondition state trees, applier to apply their messages, and
+// receiptStore to compute the resulting receipts root.
+func NewDriver(stateLoader StateLoader, applier MessageApplier, receiptStore ReceiptStore) *Driver {
+	return &Driver{
+		stateLoader:  stateLoader,
+		applier:      applier,
+		receiptStore: receiptStore,
+	}
+}
+
+// Result reports whether a vector's actual outcome matched its
+// Postconditions, and if not, a human-readable diff of every field that
+// didn't.
+type Result struct {
+	VectorID string
+	Pass     bool
+	Diffs    []string
+}
+
+// LoadVectors reads every *.json file in dir and parses it as a Vector. A
+// missing dir is not an error: it is treated as zero vectors so an
+// unfetched, git-submodule'd external corpus doesn't fail the build.
+func LoadVectors(dir string) ([]Vector, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "glob vectors in %s", dir)
+	}
+
+	vectors := make([]Vector, 0, len(paths))
+	for _, p := range paths {
+		raw, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read vector %s", p)
+		}
+		var v Vector
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, errors.Wrapf(err, "parse vector %s", p)
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+// Run applies v's Preconditions and reports how the result compares against
+// its Postconditions.
+func (d *Driver) Run(ctx context.Context, v Vector) (*Result, error) {
+	store, err := loadCAR(v.Preconditions.CARBase64)
+	if err != nil {
+		return nil, errors.Wrap(err, "load precondition CAR")
+	}
+
+	root, err := cid.Decode(v.Preconditions.StateRootCID)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse precondition state root")
+	}
+	stateTree, err := d.stateLoader.LoadStateTree(ctx, store, root)
+	if err != nil {
+		return nil, errors.Wrap(err, "load precondition state tree")
+	}
+
+	miner, err := address.NewFromString(v.Preconditions.Miner)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse miner address")
+	}
+
+	messages, err := decodeMessages(v.Preconditions.Messages)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode messages")
+	}
+
+	vms := vm.NewStorageMap(store)
+	res, err := d.applier.ApplyMessagesAndPayRewards(ctx, stateTree, vms, messages, miner, types.NewBlockHeight(v.Preconditions.Epoch), nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "apply messages")
+	}
+
+	newStateRoot, err := stateTree.Flush(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "flush state tree")
+	}
+	if err := vms.Flush(); err != nil {
+		return nil, errors.Wrap(err, "flush vm storage map")
+	}
+
+	receipts := []*types.MessageReceipt{}
+	var gasUsed uint64
+	for _, r := range res.Results {
+		receipts = append(receipts, r.Receipt)
+		gasUsed += uint64(r.Receipt.GasUsed)
+	}
+	receiptsRoot, err := d.receiptStore.StoreReceipts(ctx, receipts)
+	if err != nil {
+		return nil, errors.Wrap(err, "store receipts")
+	}
+
+	return diffResult(v.Meta.ID, v.Postconditions, newStateRoot, receiptsRoot, gasUsed), nil
+}
+
+// diffResult compares an actual outcome against want, producing a Result
+// whose Diffs name every field that didn't match so a failing vector is
+// debuggable without re-running it under a debugger.
+func diffResult(vectorID string, want Postconditions, gotStateRoot, gotReceiptsRoot cid.Cid, gotGasUsed uint64) *Result {
+	var diffs []string
+	if gotStateRoot.String() != want.StateRootCID {
+		diffs = append(diffs, fmt.Sprintf("state_root_cid: want %s, got %s", want.StateRootCID, gotStateRoot))
+	}
+	if gotReceiptsRoot.String() != want.ReceiptsRoot {
+		diffs = append(diffs, fmt.Sprintf("receipts_root: want %s, got %s", want.ReceiptsRoot, gotReceiptsRoot))
+	}
+	if gotGasUsed != want.GasUsed {
+		diffs = append(diffs, fmt.Sprintf("gas_used: want %d, got %d", want.GasUsed, gotGasUsed))
+	}
+	return &Result{VectorID: vectorID, Pass: len(diffs) == 0, Diffs: diffs}
+}
+
+// loadCAR decodes carB64 and loads every block it contains into a fresh
+// in-memory blockstore.
+func loadCAR(carB64 string) (vm.Blockstore, error) {
+	raw, err := base64.StdEncoding.DecodeString(carB64)
+	if err != nil {
+		return nil, errors.Wrap(err, "base64 decode CAR")
+	}
+
+	store := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	if _, err := car.LoadCar(store, bytes.NewReader(raw)); err != nil {
+		return nil, errors.Wrap(err, "load CAR into blockstore")
+	}
+	return store, nil
+}
+
+// decodeMessages decodes each base64'd, CBOR-encoded message in raw, in
+// order.
+func decodeMessages(raw []string) ([]*types.SignedMessage, error) {
+	messages := make([]*types.SignedMessage, 0, len(raw))
+	for i, m := range raw {
+		data, err := base64.StdEncoding.DecodeString(m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "base64 decode message %d", i)
+		}
+		msg := &types.SignedMessage{}
+		if err := msg.UnmarshalCBOR(bytes.NewReader(data)); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal message %d", i)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
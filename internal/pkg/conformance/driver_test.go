@@ -0,0 +1,193 @@
+package conformance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+// externalVectorsEnvVar optionally points at a corpus vendored in outside
+// this package, e.g. a lotus test-vectors checkout pulled in as a git
+// submodule. It is unset by default so CI doesn't need the submodule
+// initialized.
+const externalVectorsEnvVar = "FC_CONFORMANCE_VECTORS"
+
+// TestVectors loads every vector checked into vectors/, plus an external
+// corpus if FC_CONFORMANCE_VECTORS names one, and checks that each parses
+// into a well-formed Vector. Driver.Run itself needs a StateLoader and
+// MessageApplier wired to this implementation's real state-tree and
+// message-application code, which is the job of whoever constructs a
+// DefaultWorker for these tests, not of this package.
+func TestVectors(t *testing.T) {
+	tf.UnitTest(t)
+
+	dirs := []string{"vectors"}
+	if extra := os.Getenv(externalVectorsEnvVar); extra != "" {
+		dirs = append(dirs, extra)
+	}
+
+	for _, dir := range dirs {
+		vectors, err := LoadVectors(dir)
+		require.NoError(t, err)
+		if dir == "vectors" {
+			require.NotEmpty(t, vectors, "checked-in vectors directory should not be empty")
+		}
+
+		for _, v := range vectors {
+			v := v
+			t.Run(v.Meta.ID, func(t *testing.T) {
+				assert.NotEmpty(t, v.Meta.ID)
+				assert.NotEmpty(t, v.Preconditions.StateRootCID)
+				assert.NotEmpty(t, v.Postconditions.StateRootCID)
+			})
+		}
+	}
+}
+
+// TestDriverRunReplaysVector wires a Driver to fakes standing in for the
+// real state-tree and message-application code, and actually calls Run, so
+// a break in Run's CAR-loading, message-application or diffing logic fails
+// this package's own tests rather than only surfacing once something wires
+// a DefaultWorker up to it.
+func TestDriverRunReplaysVector(t *testing.T) {
+	tf.UnitTest(t)
+
+	vectors, err := LoadVectors("vectors")
+	require.NoError(t, err)
+	require.NotEmpty(t, vectors)
+	v := vectors[0]
+
+	// Have the fakes reproduce the vector's own postconditions, so Run
+	// reports a pass; TestDriverRunFlagsMismatch below covers the failure
+	// path.
+	wantStateRoot, err := cid.Decode(v.Postconditions.StateRootCID)
+	require.NoError(t, err)
+	wantReceiptsRoot, err := cid.Decode(v.Postconditions.ReceiptsRoot)
+	require.NoError(t, err)
+
+	driver := NewDriver(
+		&fakeStateLoader{tree: &fakeStateTree{flushed: wantStateRoot}},
+		&fakeApplier{gasUsed: v.Postconditions.GasUsed},
+		&fakeReceiptStore{root: wantReceiptsRoot},
+	)
+
+	result, err := driver.Run(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, v.Meta.ID, result.VectorID)
+	assert.True(t, result.Pass)
+	assert.Empty(t, result.Diffs)
+}
+
+// TestDriverRunFlagsMismatch checks that Run reports a failing Result, with
+// a diff naming the mismatched field, when the applied outcome doesn't
+// match a vector's postconditions; this is the case the whole harness
+// exists to catch.
+func TestDriverRunFlagsMismatch(t *testing.T) {
+	tf.UnitTest(t)
+
+	vectors, err := LoadVectors("vectors")
+	require.NoError(t, err)
+	require.NotEmpty(t, vectors)
+	v := vectors[0]
+
+	driver := NewDriver(
+		&fakeStateLoader{tree: &fakeStateTree{flushed: testCid(t, "unexpected-post-state")}},
+		&fakeApplier{gasUsed: v.Postconditions.GasUsed},
+		&fakeReceiptStore{root: testCid(t, "unexpected-post-receipts")},
+	)
+
+	result, err := driver.Run(context.Background(), v)
+	require.NoError(t, err)
+	assert.False(t, result.Pass)
+	assert.Len(t, result.Diffs, 2)
+}
+
+// fakeStateTree stands in for vm.StateTree: Run only ever flushes it, so
+// that is all it needs to do.
+type fakeStateTree struct {
+	flushed cid.Cid
+}
+
+func (t *fakeStateTree) Flush(ctx context.Context) (cid.Cid, error) {
+	return t.flushed, nil
+}
+
+// fakeStateLoader returns a fixed state tree regardless of the CAR or root
+// it's asked to load, so Run's own logic can be tested without a real
+// vm.StateTree implementation.
+type fakeStateLoader struct {
+	tree vm.StateTree
+}
+
+func (l *fakeStateLoader) LoadStateTree(ctx context.Context, store vm.Blockstore, root cid.Cid) (vm.StateTree, error) {
+	return l.tree, nil
+}
+
+// fakeApplier reports a fixed gas usage and no messages, successful or
+// failed, regardless of what it's asked to apply.
+type fakeApplier struct {
+	gasUsed uint64
+}
+
+func (a *fakeApplier) ApplyMessagesAndPayRewards(ctx context.Context, st vm.StateTree, vms vm.StorageMap, messages []*types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, ancestors []block.TipSet, rngSeed []byte) (*vm.ApplyMessagesResponse, error) {
+	return &vm.ApplyMessagesResponse{
+		Results: []*vm.MessageApplicationResult{{Receipt: &types.MessageReceipt{GasUsed: types.GasUnits(a.gasUsed)}}},
+	}, nil
+}
+
+// fakeReceiptStore returns a fixed receipts root regardless of the receipts
+// it's asked to store.
+type fakeReceiptStore struct {
+	root cid.Cid
+}
+
+func (s *fakeReceiptStore) StoreReceipts(ctx context.Context, receipts []*types.MessageReceipt) (cid.Cid, error) {
+	return s.root, nil
+}
+
+func TestLoadVectorsMissingDirIsNotAnError(t *testing.T) {
+	tf.UnitTest(t)
+
+	vectors, err := LoadVectors(filepath.Join("vectors", "does-not-exist"))
+	require.NoError(t, err)
+	assert.Empty(t, vectors)
+}
+
+func TestDiffResultPass(t *testing.T) {
+	tf.UnitTest(t)
+
+	want := Postconditions{StateRootCID: testCid(t, "a").String(), ReceiptsRoot: testCid(t, "b").String(), GasUsed: 42}
+	result := diffResult("v1", want, testCid(t, "a"), testCid(t, "b"), 42)
+
+	assert.True(t, result.Pass)
+	assert.Empty(t, result.Diffs)
+}
+
+func TestDiffResultReportsEveryMismatch(t *testing.T) {
+	tf.UnitTest(t)
+
+	want := Postconditions{StateRootCID: testCid(t, "a").String(), ReceiptsRoot: testCid(t, "b").String(), GasUsed: 42}
+	result := diffResult("v1", want, testCid(t, "z"), testCid(t, "z"), 7)
+
+	assert.False(t, result.Pass)
+	require.Len(t, result.Diffs, 3)
+}
+
+func testCid(t *testing.T, seed string) cid.Cid {
+	t.Helper()
+	sum, err := mh.Sum([]byte(seed), mh.SHA2_256, -1)
+	require.NoError(t, err)
+	return cid.NewCidV1(cid.Raw, sum)
+}
@@ -0,0 +1,48 @@
+// Package conformance replays checked-in test vectors against message
+// application (ApplyMessagesAndPayRewards) and reports any divergence from
+// their expected outcome. It does not build full blocks through Generate.
+// Vectors are plain
+// JSON so they can be generated by, or replayed against, other Filecoin
+// implementations such as lotus, catching reward- and message-application
+// divergences without standing up a devnet.
+package conformance
+
+// Vector is a single conformance test case.
+type Vector struct {
+	Meta           Meta           `json:"meta"`
+	Preconditions  Preconditions  `json:"preconditions"`
+	Postconditions Postconditions `json:"postconditions"`
+}
+
+// Meta identifies a vector and records where it came from.
+type Meta struct {
+	ID      string `json:"id"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// Preconditions describe the chain state a vector's messages are applied
+// against.
+type Preconditions struct {
+	// CARBase64 is a base64-encoded CAR of every IPLD block StateRootCID
+	// transitively references.
+	CARBase64 string `json:"car_base64"`
+	// StateRootCID is the root of the state tree to apply Messages against.
+	StateRootCID string `json:"state_root_cid"`
+	// Epoch is the block height the messages are applied at.
+	Epoch uint64 `json:"epoch"`
+	// Miner is the block miner address paid the reward.
+	Miner string `json:"miner"`
+	// Messages are applied in order, CBOR-encoded then base64'd.
+	Messages []string `json:"messages"`
+}
+
+// Postconditions describe the expected outcome of applying a vector's
+// Preconditions.
+type Postconditions struct {
+	// StateRootCID is the state tree root application must produce.
+	StateRootCID string `json:"state_root_cid"`
+	// ReceiptsRoot is the root of the receipts application must produce.
+	ReceiptsRoot string `json:"receipts_root"`
+	// GasUsed is the total gas every message must have consumed.
+	GasUsed uint64 `json:"gas_used"`
+}
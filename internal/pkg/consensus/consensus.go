@@ -0,0 +1,79 @@
+// Package consensus selects and constructs the iface.Consensus
+// implementation a node mines and validates under, so that choice lives in
+// configuration rather than being hardcoded at the call site.
+package consensus
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus/expected"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus/iface"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus/localdev"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+// Mode selects which iface.Consensus implementation a node constructs.
+type Mode string
+
+const (
+	// ModeExpected is today's network consensus: a miner may only produce a
+	// block once the power table shows it holds power, and competing
+	// chains are resolved by weight. This is the default when Mode is
+	// unset.
+	ModeExpected Mode = "expected"
+	// ModeLocalDev is the single-miner, proof-of-authority rule set: every
+	// miner always wins and blocks are signed with a fixed, well-known key.
+	// It must never be configured outside local devnets.
+	ModeLocalDev Mode = "localdev"
+)
+
+// ExpectedDeps bundles the dependencies ModeExpected needs to construct an
+// expected.Consensus.
+type ExpectedDeps struct {
+	StateViewer    expected.StateViewer
+	PowerTableView expected.PowerTableView
+	Applier        expected.MessageApplier
+	MessageStore   expected.MessageStore
+	MessagePool    expected.MessagePool
+	Signer         expected.BlockSigner
+	Blockstore     vm.Blockstore
+}
+
+// LocalDevDeps bundles the dependencies ModeLocalDev needs to construct a
+// localdev.Consensus.
+type LocalDevDeps struct {
+	StateViewer  localdev.StateViewer
+	Applier      localdev.MessageApplier
+	MessageStore localdev.MessageStore
+	Blockstore   vm.Blockstore
+	SigningKey   types.KeyInfo
+}
+
+// NewFromConfig constructs the iface.Consensus mode selects. Only the deps
+// bundle matching mode needs to be populated; the other may be the zero
+// value.
+func NewFromConfig(mode Mode, expectedDeps ExpectedDeps, localDevDeps LocalDevDeps) (iface.Consensus, error) {
+	switch mode {
+	case ModeExpected, "":
+		return expected.New(
+			expectedDeps.StateViewer,
+			expectedDeps.PowerTableView,
+			expectedDeps.Applier,
+			expectedDeps.MessageStore,
+			expectedDeps.MessagePool,
+			expectedDeps.Signer,
+			expectedDeps.Blockstore,
+		), nil
+	case ModeLocalDev:
+		return localdev.New(
+			localDevDeps.StateViewer,
+			localDevDeps.Applier,
+			localDevDeps.MessageStore,
+			localDevDeps.Blockstore,
+			localDevDeps.SigningKey,
+		), nil
+	default:
+		return nil, errors.Errorf("unknown consensus mode %q", mode)
+	}
+}
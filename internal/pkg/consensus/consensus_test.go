@@ -0,0 +1,43 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus/expected"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus/localdev"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestNewFromConfigDefaultsToExpected(t *testing.T) {
+	tf.UnitTest(t)
+
+	c, err := NewFromConfig("", ExpectedDeps{}, LocalDevDeps{})
+	require.NoError(t, err)
+	assert.IsType(t, &expected.Consensus{}, c)
+}
+
+func TestNewFromConfigSelectsExpected(t *testing.T) {
+	tf.UnitTest(t)
+
+	c, err := NewFromConfig(ModeExpected, ExpectedDeps{}, LocalDevDeps{})
+	require.NoError(t, err)
+	assert.IsType(t, &expected.Consensus{}, c)
+}
+
+func TestNewFromConfigSelectsLocalDev(t *testing.T) {
+	tf.UnitTest(t)
+
+	c, err := NewFromConfig(ModeLocalDev, ExpectedDeps{}, LocalDevDeps{})
+	require.NoError(t, err)
+	assert.IsType(t, &localdev.Consensus{}, c)
+}
+
+func TestNewFromConfigRejectsUnknownMode(t *testing.T) {
+	tf.UnitTest(t)
+
+	_, err := NewFromConfig(Mode("bogus"), ExpectedDeps{}, LocalDevDeps{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,288 @@
+// Package expected implements today's block production and validation rules
+// (Expected Consensus) behind the iface.Consensus interface, so DefaultWorker
+// can be pointed at a different rule set without code changes.
+package expected
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-bls-sigs"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/beacon"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+// StateViewer loads the chain state a block extends: its state tree, its
+// ancestor tipsets, and the weight of the chain up to it.
+type StateViewer interface {
+	GetStateTree(ctx context.Context, ts block.TipSet) (vm.StateTree, error)
+	GetAncestors(ctx context.Context, ts block.TipSet, newBlockHeight *types.BlockHeight) ([]block.TipSet, error)
+	GetWeight(ctx context.Context, ts block.TipSet) (uint64, error)
+}
+
+// PowerTableView reports a miner's share of network power as of a tipset,
+// used to gate block production and winner checks.
+type PowerTableView interface {
+	HasPower(ctx context.Context, tsKey block.TipSetKey, miner address.Address) (bool, error)
+
+	// MinerAndTotalPower returns miner's power and the network's total power
+	// as of tsKey, the inputs ValidateBlock needs to check an Election PoSt
+	// proof's candidates against the per-sector winning threshold.
+	MinerAndTotalPower(ctx context.Context, tsKey block.TipSetKey, miner address.Address) (minerPower uint64, totalPower uint64, err error)
+}
+
+// MessageApplier applies a block's messages against a state tree and pays
+// out the block reward.
+type MessageApplier interface {
+	ApplyMessagesAndPayRewards(ctx context.Context, st vm.StateTree, vms vm.StorageMap, messages []*types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, ancestors []block.TipSet, rngSeed []byte) (*vm.ApplyMessagesResponse, error)
+}
+
+// MessageStore persists a block's messages and receipts to IPLD storage.
+type MessageStore interface {
+	StoreMessages(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) (types.TxMeta, error)
+	StoreReceipts(ctx context.Context, receipts []*types.MessageReceipt) (cid.Cid, error)
+}
+
+// MessagePool removes messages that can never be applied.
+type MessagePool interface {
+	Remove(c cid.Cid)
+}
+
+// BlockSigner signs the bytes of a block header on behalf of a worker
+// address.
+type BlockSigner interface {
+	SignBytes(data []byte, addr address.Address) (types.Signature, error)
+}
+
+// Consensus implements iface.Consensus with the network's current Expected
+// Consensus rules: a miner may produce a block only once the power table
+// shows it holds power, and the winning chain is whichever carries the
+// greatest weight.
+type Consensus struct {
+	stateViewer    StateViewer
+	powerTableView PowerTableView
+	applier        MessageApplier
+	messageStore   MessageStore
+	messagePool    MessagePool
+	signer         BlockSigner
+	blockstore     vm.Blockstore
+}
+
+// New returns a Consensus implementing today's Expected Consensus rules.
+func New(stateViewer StateViewer, powerTableView PowerTableView, applier MessageApplier, messageStore MessageStore, messagePool MessagePool, signer BlockSigner, blockstore vm.Blockstore) *Consensus {
+	return &Consensus{
+		stateViewer:    stateViewer,
+		powerTableView: powerTableView,
+		applier:        applier,
+		messageStore:   messageStore,
+		messagePool:    messagePool,
+		signer:         signer,
+		blockstore:     blockstore,
+	}
+}
+
+// CreateBlock fetches the state tree rooted at base, applies msgs and pays
+// the block reward to winner, persists the resulting messages and receipts,
+// and returns the resulting unsigned block at epoch. rngSeed is passed
+// through to the applier unmodified; a nil seed means message application
+// falls back to its own source of VM randomness. The caller is responsible
+// for signing the returned block via SignBlock.
+func (c *Consensus) CreateBlock(ctx context.Context, base block.TipSet, msgs []*types.SignedMessage, epoch uint64, winner address.Address, rngSeed []byte) (*block.Block, error) {
+	hasPower, err := c.powerTableView.HasPower(ctx, base.Key(), winner)
+	if err != nil {
+		return nil, errors.Wrap(err, "get power table")
+	}
+	if !hasPower {
+		return nil, errors.Errorf("bad miner address, miner must store files before mining: %s", winner)
+	}
+
+	stateTree, err := c.stateViewer.GetStateTree(ctx, base)
+	if err != nil {
+		return nil, errors.Wrap(err, "get state tree")
+	}
+
+	weight, err := c.stateViewer.GetWeight(ctx, base)
+	if err != nil {
+		return nil, errors.Wrap(err, "get weight")
+	}
+
+	ancestors, err := c.stateViewer.GetAncestors(ctx, base, types.NewBlockHeight(epoch))
+	if err != nil {
+		return nil, errors.Wrap(err, "get base tip set ancestors")
+	}
+
+	vms := vm.NewStorageMap(c.blockstore)
+	res, err := c.applier.ApplyMessagesAndPayRewards(ctx, stateTree, vms, msgs, winner, types.NewBlockHeight(epoch), ancestors, rngSeed)
+	if err != nil {
+		return nil, errors.Wrap(err, "apply messages")
+	}
+
+	newStateTreeCid, err := stateTree.Flush(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "flush state tree")
+	}
+	if err := vms.Flush(); err != nil {
+		return nil, errors.Wrap(err, "flush vm storage map")
+	}
+
+	// By default no receipts/messages is serialized as the zero length
+	// slice, not the nil slice.
+	receipts := []*types.MessageReceipt{}
+	for _, r := range res.Results {
+		receipts = append(receipts, r.Receipt)
+	}
+
+	minedSecpMessages, minedBLSMessages := divideMessages(res.SuccessfulMessages)
+
+	unwrappedBLSMessages, blsAggregateSig, err := aggregateBLS(minedBLSMessages)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not aggregate bls messages")
+	}
+
+	txMeta, err := c.messageStore.StoreMessages(ctx, minedSecpMessages, unwrappedBLSMessages)
+	if err != nil {
+		return nil, errors.Wrap(err, "error persisting messages")
+	}
+	rcptsCid, err := c.messageStore.StoreReceipts(ctx, receipts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error persisting receipts")
+	}
+
+	next := &block.Block{
+		Miner:           winner,
+		Height:          types.Uint64(epoch),
+		Messages:        txMeta,
+		MessageReceipts: rcptsCid,
+		Parents:         base.Key(),
+		ParentWeight:    types.Uint64(weight),
+		StateRoot:       newStateTreeCid,
+		Timestamp:       types.Uint64(time.Now().Unix()),
+		BLSAggregateSig: blsAggregateSig,
+	}
+
+	for _, msg := range res.PermanentFailures {
+		// This message will never apply; drop it from the pool so it doesn't
+		// perpetually block the messages behind it.
+		if mc, err := msg.Cid(); err == nil {
+			c.messagePool.Remove(mc)
+		}
+	}
+
+	return next, nil
+}
+
+// SignBlock signs blk on behalf of workerAddr, the address of the worker
+// that actually produced it. This is distinct from blk.Miner, which is the
+// miner actor address credited with the block and has no signing key of its
+// own.
+func (c *Consensus) SignBlock(ctx context.Context, blk *block.Block, workerAddr address.Address) error {
+	sig, err := c.signer.SignBytes(blk.SignatureData(), workerAddr)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign block")
+	}
+	blk.BlockSig = sig
+	return nil
+}
+
+// ComputeWeight returns the chain weight of base.
+func (c *Consensus) ComputeWeight(ctx context.Context, base block.TipSet) (uint64, error) {
+	return c.stateViewer.GetWeight(ctx, base)
+}
+
+// ValidateBlock checks that blk was produced by a miner holding power as of
+// base, that its beacon entries form a single unbroken chain, that its
+// Election PoSt proof was challenged with the seed those entries require,
+// and that every candidate partial ticket beats the per-sector winning
+// threshold for blk.Miner's share of power. It does not verify
+// EPoStInfo.PoStProof itself, the SNARK attesting the candidates were
+// actually drawn from blk.Miner's sealed sectors — this package has no
+// sector-sealing proving code to check it against, so that check is left to
+// a future change. Combined with message and signature validity (handled
+// upstream by the syncer), this is everything else Expected Consensus
+// requires of a block.
+func (c *Consensus) ValidateBlock(ctx context.Context, base block.TipSet, blk *block.Block) error {
+	hasPower, err := c.powerTableView.HasPower(ctx, base.Key(), blk.Miner)
+	if err != nil {
+		return errors.Wrap(err, "get power table")
+	}
+	if !hasPower {
+		return errors.Errorf("block miner %s does not hold power as of %s", blk.Miner, base.Key())
+	}
+
+	if len(blk.BeaconEntries) == 0 {
+		return errors.Errorf("block %s has no beacon entries", blk.Miner)
+	}
+	if err := beacon.ValidateEntryChain(blk.BeaconEntries); err != nil {
+		return errors.Wrap(err, "invalid beacon entry chain")
+	}
+
+	latestEntry := blk.BeaconEntries[len(blk.BeaconEntries)-1]
+	wantSeed := electionChallengeSeed(latestEntry, blk.Miner, uint64(blk.Height))
+	if !bytes.Equal(blk.EPoStInfo.PostRand, wantSeed) {
+		return errors.Errorf("block %s election PoSt challenge seed does not match its beacon entries", blk.Miner)
+	}
+
+	minerPower, totalPower, err := c.powerTableView.MinerAndTotalPower(ctx, base.Key(), blk.Miner)
+	if err != nil {
+		return errors.Wrap(err, "get miner and total power")
+	}
+	if err := blk.EPoStInfo.ValidateCandidates(minerPower, totalPower); err != nil {
+		return errors.Wrap(err, "invalid election PoSt proof")
+	}
+
+	return nil
+}
+
+// electionChallengeSeed derives the Election PoSt challenge seed a block
+// produced at epoch with latestEntry as its most recent beacon entry must
+// have used, so ValidateBlock can check a block's EPoStInfo.PostRand against
+// it instead of trusting whatever the block claims.
+func electionChallengeSeed(latestEntry beacon.BeaconEntry, miner address.Address, epoch uint64) []byte {
+	return beacon.Seed(latestEntry, miner, epoch)
+}
+
+// CheckWinner reports whether ticket wins the right to mine the next block
+// on top of base. The ticket's own VRF proof is the evidence of the win; this
+// is a pass-through once the caller already holds power.
+func (c *Consensus) CheckWinner(ctx context.Context, base block.TipSet, ticket block.Ticket) (bool, block.VRFPi, error) {
+	return true, block.VRFPi(ticket.VRFProof), nil
+}
+
+func aggregateBLS(blsMessages []*types.SignedMessage) ([]*types.UnsignedMessage, types.Signature, error) {
+	sigs := []bls.Signature{}
+	unwrappedMsgs := []*types.UnsignedMessage{}
+	for _, msg := range blsMessages {
+		unwrappedMsgs = append(unwrappedMsgs, &msg.Message)
+		sig := msg.Signature
+
+		blsSig := bls.Signature{}
+		copy(blsSig[:], sig)
+		sigs = append(sigs, blsSig)
+	}
+	blsAggregateSig := bls.Aggregate(sigs)
+	if blsAggregateSig == nil {
+		return []*types.UnsignedMessage{}, types.Signature{}, errors.New("could not aggregate signatures")
+	}
+	return unwrappedMsgs, blsAggregateSig[:], nil
+}
+
+func divideMessages(messages []*types.SignedMessage) ([]*types.SignedMessage, []*types.SignedMessage) {
+	secpMessages := []*types.SignedMessage{}
+	blsMessages := []*types.SignedMessage{}
+
+	for _, m := range messages {
+		if m.Message.From.Protocol() == address.BLS {
+			blsMessages = append(blsMessages, m)
+		} else {
+			secpMessages = append(secpMessages, m)
+		}
+	}
+	return secpMessages, blsMessages
+}
@@ -0,0 +1,49 @@
+// Package iface defines the boundary between the miner and the consensus
+// rules it mines under. Everything a DefaultWorker needs from "the rules of
+// the chain" - deciding whether a ticket wins, weighing a chain, assembling
+// and validating a block - is expressed here so that alternative rule sets
+// can be swapped in without forking the miner.
+package iface
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// Consensus abstracts the rules used to validate, weigh, and produce blocks
+// on top of a given base TipSet. ExpectedConsensus implements today's
+// behavior; other implementations (a local development PoA, a future EC
+// variant) can be wired into DefaultWorker in its place.
+type Consensus interface {
+	// ValidateBlock checks that blk is a legal extension of base under these
+	// consensus rules.
+	ValidateBlock(ctx context.Context, base block.TipSet, blk *block.Block) error
+
+	// ComputeWeight returns the chain weight of base under these consensus
+	// rules.
+	ComputeWeight(ctx context.Context, base block.TipSet) (uint64, error)
+
+	// CreateBlock assembles a new block extending base at epoch with msgs
+	// applied, crediting winner as the block's miner. It performs the state
+	// transition (state-tree fetch, reward application, ancestor lookup) and
+	// returns the resulting block unsigned, ready for the caller to attach
+	// tickets, beacon entries and a signature. rngSeed, if non-nil, is
+	// threaded through to every place message application consumes VM
+	// randomness, making the resulting state transition reproducible;
+	// production mining leaves it nil.
+	CreateBlock(ctx context.Context, base block.TipSet, msgs []*types.SignedMessage, epoch uint64, winner address.Address, rngSeed []byte) (*block.Block, error)
+
+	// SignBlock signs blk's header on behalf of workerAddr, the address of
+	// the worker that produced it, and sets blk.BlockSig. Consensus owns the
+	// decision of which key actually signs: ExpectedConsensus signs with
+	// workerAddr's real key, while a development rule set may sign every
+	// block with a fixed key regardless of workerAddr.
+	SignBlock(ctx context.Context, blk *block.Block, workerAddr address.Address) error
+
+	// CheckWinner reports whether ticket wins the right to mine the next
+	// block on top of base, along with the VRF proof backing that claim.
+	CheckWinner(ctx context.Context, base block.TipSet, ticket block.Ticket) (bool, block.VRFPi, error)
+}
@@ -0,0 +1,145 @@
+// Package localdev implements a proof-of-authority iface.Consensus for
+// single-miner local devnets and tests, where waiting on the power table and
+// signing with a worker's real key just slow down iteration.
+package localdev
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+// StateViewer loads the chain state a block extends.
+type StateViewer interface {
+	GetStateTree(ctx context.Context, ts block.TipSet) (vm.StateTree, error)
+	GetAncestors(ctx context.Context, ts block.TipSet, newBlockHeight *types.BlockHeight) ([]block.TipSet, error)
+}
+
+// MessageApplier applies a block's messages against a state tree and pays
+// out the block reward.
+type MessageApplier interface {
+	ApplyMessagesAndPayRewards(ctx context.Context, st vm.StateTree, vms vm.StorageMap, messages []*types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, ancestors []block.TipSet, rngSeed []byte) (*vm.ApplyMessagesResponse, error)
+}
+
+// MessageStore persists a block's messages and receipts to IPLD storage.
+type MessageStore interface {
+	StoreMessages(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) (types.TxMeta, error)
+	StoreReceipts(ctx context.Context, receipts []*types.MessageReceipt) (cid.Cid, error)
+}
+
+// Consensus is a development-only iface.Consensus: every miner always wins,
+// the power-table gate in CreateBlock is skipped, and blocks are signed with
+// a fixed, well-known key rather than the worker's real one. It must never be
+// wired up outside of local devnets.
+type Consensus struct {
+	stateViewer  StateViewer
+	applier      MessageApplier
+	messageStore MessageStore
+	blockstore   vm.Blockstore
+	signingKey   types.KeyInfo
+}
+
+// New returns a LocalDev Consensus that signs blocks with signingKey instead
+// of consulting a worker's real key.
+func New(stateViewer StateViewer, applier MessageApplier, messageStore MessageStore, blockstore vm.Blockstore, signingKey types.KeyInfo) *Consensus {
+	return &Consensus{
+		stateViewer:  stateViewer,
+		applier:      applier,
+		messageStore: messageStore,
+		blockstore:   blockstore,
+		signingKey:   signingKey,
+	}
+}
+
+// CreateBlock skips the power-table gate entirely, otherwise performing the
+// same state transition as Expected Consensus, and returns the resulting
+// block unsigned; SignBlock is what actually applies the fixed devnet key.
+func (c *Consensus) CreateBlock(ctx context.Context, base block.TipSet, msgs []*types.SignedMessage, epoch uint64, winner address.Address, rngSeed []byte) (*block.Block, error) {
+	stateTree, err := c.stateViewer.GetStateTree(ctx, base)
+	if err != nil {
+		return nil, errors.Wrap(err, "get state tree")
+	}
+
+	ancestors, err := c.stateViewer.GetAncestors(ctx, base, types.NewBlockHeight(epoch))
+	if err != nil {
+		return nil, errors.Wrap(err, "get base tip set ancestors")
+	}
+
+	vms := vm.NewStorageMap(c.blockstore)
+	res, err := c.applier.ApplyMessagesAndPayRewards(ctx, stateTree, vms, msgs, winner, types.NewBlockHeight(epoch), ancestors, rngSeed)
+	if err != nil {
+		return nil, errors.Wrap(err, "apply messages")
+	}
+
+	newStateTreeCid, err := stateTree.Flush(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "flush state tree")
+	}
+	if err := vms.Flush(); err != nil {
+		return nil, errors.Wrap(err, "flush vm storage map")
+	}
+
+	receipts := []*types.MessageReceipt{}
+	for _, r := range res.Results {
+		receipts = append(receipts, r.Receipt)
+	}
+
+	txMeta, err := c.messageStore.StoreMessages(ctx, res.SuccessfulMessages, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error persisting messages")
+	}
+	rcptsCid, err := c.messageStore.StoreReceipts(ctx, receipts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error persisting receipts")
+	}
+
+	next := &block.Block{
+		Miner:           winner,
+		Height:          types.Uint64(epoch),
+		Messages:        txMeta,
+		MessageReceipts: rcptsCid,
+		Parents:         base.Key(),
+		StateRoot:       newStateTreeCid,
+		Timestamp:       types.Uint64(time.Now().Unix()),
+	}
+
+	return next, nil
+}
+
+// SignBlock signs blk with the fixed devnet key, ignoring workerAddr: every
+// block on a local devnet is signed by the same well-known key regardless of
+// which worker produced it.
+func (c *Consensus) SignBlock(ctx context.Context, blk *block.Block, workerAddr address.Address) error {
+	sig, err := crypto.SignBytes(blk.SignatureData(), c.signingKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign block with devnet key")
+	}
+	blk.BlockSig = sig
+	return nil
+}
+
+// ComputeWeight always reports a weight of 1: there is only ever one devnet
+// miner, so chains never need to be compared.
+func (c *Consensus) ComputeWeight(ctx context.Context, base block.TipSet) (uint64, error) {
+	return 1, nil
+}
+
+// ValidateBlock performs no checks: every block is accepted, and its
+// signature is not checked against the fixed devnet key.
+func (c *Consensus) ValidateBlock(ctx context.Context, base block.TipSet, blk *block.Block) error {
+	return nil
+}
+
+// CheckWinner always reports a win: local devnets have exactly one miner and
+// no need to arbitrate between competing tickets.
+func (c *Consensus) CheckWinner(ctx context.Context, base block.TipSet, ticket block.Ticket) (bool, block.VRFPi, error) {
+	return true, block.VRFPi(ticket.VRFProof), nil
+}
@@ -0,0 +1,32 @@
+package mining
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/beacon"
+)
+
+// collectBeaconEntries gathers every drand beacon entry between
+// parentHeight (exclusive) and blockHeight (inclusive), deduplicating
+// consecutive epochs that land on the same drand round so null blocks
+// don't repeat an entry already in the chain.
+func collectBeaconEntries(ctx context.Context, source beacon.RandomnessSource, parentHeight, blockHeight uint64) ([]beacon.BeaconEntry, error) {
+	entries := []beacon.BeaconEntry{}
+	haveLast := false
+	var lastRound uint64
+	for epoch := parentHeight + 1; epoch <= blockHeight; epoch++ {
+		entry, err := source.EntryAt(ctx, epoch)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get beacon entry at epoch %d", epoch)
+		}
+		if haveLast && entry.Round == lastRound {
+			continue
+		}
+		entries = append(entries, entry)
+		lastRound = entry.Round
+		haveLast = true
+	}
+	return entries, nil
+}
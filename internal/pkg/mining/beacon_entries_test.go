@@ -0,0 +1,53 @@
+package mining
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/beacon"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+// fakeRandomnessSource returns a fixed entry per round regardless of the
+// epoch it's queried at, so collectBeaconEntries' own deduplication logic
+// can be tested without a real drand client.
+type fakeRandomnessSource struct {
+	// roundAt maps epoch to the round in effect at that epoch.
+	roundAt map[uint64]uint64
+}
+
+func (s *fakeRandomnessSource) EntryAt(ctx context.Context, epoch uint64) (beacon.BeaconEntry, error) {
+	return beacon.BeaconEntry{Round: s.roundAt[epoch]}, nil
+}
+
+func TestCollectBeaconEntriesDedupesRepeatedRounds(t *testing.T) {
+	tf.UnitTest(t)
+
+	source := &fakeRandomnessSource{roundAt: map[uint64]uint64{
+		11: 5,
+		12: 5, // a null block: same round as epoch 11
+		13: 6,
+	}}
+
+	entries, err := collectBeaconEntries(context.Background(), source, 10, 13)
+	require.NoError(t, err)
+
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(5), entries[0].Round)
+	assert.Equal(t, uint64(6), entries[1].Round)
+}
+
+func TestCollectBeaconEntriesSingleEpoch(t *testing.T) {
+	tf.UnitTest(t)
+
+	source := &fakeRandomnessSource{roundAt: map[uint64]uint64{11: 5}}
+
+	entries, err := collectBeaconEntries(context.Background(), source, 10, 11)
+	require.NoError(t, err)
+
+	require.Len(t, entries, 1)
+	assert.Equal(t, uint64(5), entries[0].Round)
+}
@@ -6,48 +6,59 @@ package mining
 
 import (
 	"context"
+	"sort"
 	"time"
 
-	"github.com/filecoin-project/go-bls-sigs"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
-	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 )
 
+// Timestamper computes the timestamp Generate should stamp onto a block
+// built on top of baseTipSet at epoch, standing in for the real wall clock.
+// DefaultWorker.Timestamper is nil in normal mining, which uses the real
+// clock; simulation and chain-gen-style fixtures set it so their output is
+// reproducible across runs.
+type Timestamper func(baseTipSet block.TipSet, epoch uint64) uint64
+
 // Generate returns a new block created from the messages in the pool.
 func (w *DefaultWorker) Generate(ctx context.Context,
 	baseTipSet block.TipSet,
 	tickets []block.Ticket,
-	electionProof block.VRFPi,
+	eproof block.EPostProof,
 	nullBlockCount uint64) (*block.Block, error) {
+	return w.generate(ctx, baseTipSet, tickets, eproof, nullBlockCount, nil)
+}
+
+// GenerateWithSeed behaves like Generate, but threads rngSeed through to
+// every place message application consumes VM randomness, so the resulting
+// block is reproducible given the same inputs. This is what lets a
+// simulated chain, or a fuzzer replaying a past failure, produce the same
+// block across runs instead of a fresh one each time. The block's Timestamp
+// still comes from the wall clock unless w.Timestamper is set.
+func (w *DefaultWorker) GenerateWithSeed(ctx context.Context,
+	baseTipSet block.TipSet,
+	tickets []block.Ticket,
+	eproof block.EPostProof,
+	nullBlockCount uint64,
+	rngSeed []byte) (*block.Block, error) {
+	return w.generate(ctx, baseTipSet, tickets, eproof, nullBlockCount, rngSeed)
+}
+
+func (w *DefaultWorker) generate(ctx context.Context,
+	baseTipSet block.TipSet,
+	tickets []block.Ticket,
+	eproof block.EPostProof,
+	nullBlockCount uint64,
+	rngSeed []byte) (*block.Block, error) {
 
 	generateTimer := time.Now()
 	defer func() {
 		log.Infof("[TIMER] DefaultWorker.Generate baseTipset: %s - elapsed time: %s", baseTipSet.String(), time.Since(generateTimer).Round(time.Millisecond))
 	}()
 
-	stateTree, err := w.getStateTree(ctx, baseTipSet)
-	if err != nil {
-		return nil, errors.Wrap(err, "get state tree")
-	}
-
-	powerTable, err := w.getPowerTable(ctx, baseTipSet.Key())
-	if err != nil {
-		return nil, errors.Wrap(err, "get power table")
-	}
-
-	if !powerTable.HasPower(ctx, w.minerAddr) {
-		return nil, errors.Errorf("bad miner address, miner must store files before mining: %s", w.minerAddr)
-	}
-
-	weight, err := w.getWeight(ctx, baseTipSet)
-	if err != nil {
-		return nil, errors.Wrap(err, "get weight")
-	}
-
 	baseHeight, err := baseTipSet.Height()
 	if err != nil {
 		return nil, errors.Wrap(err, "get base tip set height")
@@ -55,123 +66,142 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 
 	blockHeight := baseHeight + nullBlockCount + 1
 
-	ancestors, err := w.getAncestors(ctx, baseTipSet, types.NewBlockHeight(blockHeight))
-	if err != nil {
-		return nil, errors.Wrap(err, "get base tip set ancestors")
-	}
-
 	pending := w.messageSource.Pending()
-	mq := NewMessageQueue(pending)
-	secpMessages, blsMessages := divideMessages(mq.Drain())
+	selected, oversized := SelectMessages(pending, w.BlockGasLimit, w.MaxBlockMessages)
+	for _, msg := range oversized {
+		// This message's GasLimit alone exceeds the block gas limit, so it can
+		// never be included in any block. Remove it from the pool now rather
+		// than let it block every message behind it forever.
+		log.Infof("dropping message that exceeds block gas limit, [%s]", msg)
+		if mc, err := msg.Cid(); err == nil {
+			w.messageSource.Remove(mc)
+		} else {
+			log.Warnf("failed to get CID from oversized message", err)
+		}
+	}
+	secpMessages, blsMessages := divideMessages(selected)
+	sortMessages(secpMessages)
+	sortMessages(blsMessages)
 
 	// bls messages are processed first
 	messages := append(blsMessages, secpMessages...)
 
-	vms := vm.NewStorageMap(w.blockstore)
-	res, err := w.processor.ApplyMessagesAndPayRewards(ctx, stateTree, vms, messages, w.minerOwnerAddr, types.NewBlockHeight(blockHeight), ancestors)
+	// The consensus rules own the state transition: fetching the base state
+	// tree, checking the miner holds power, computing chain weight, applying
+	// messages and paying the block reward, and assembling the resulting
+	// header, which it returns unsigned. This lets an alternative rule set (a local devnet, a
+	// future EC variant) be swapped in without touching Generate at all.
+	next, err := w.Consensus.CreateBlock(ctx, baseTipSet, messages, blockHeight, w.minerAddr, rngSeed)
 	if err != nil {
-		return nil, errors.Wrap(err, "generate apply messages")
+		return nil, errors.Wrap(err, "generate create block")
 	}
 
-	newStateTreeCid, err := stateTree.Flush(ctx)
-	if err != nil {
-		return nil, errors.Wrap(err, "generate flush state tree")
-	}
+	// Tickets and the election PoSt proof are mining-specific, not a
+	// consensus concern, so Generate attaches them itself once CreateBlock
+	// has assembled the rest of the header.
+	next.Tickets = tickets
+	next.EPoStInfo = eproof
 
-	if err = vms.Flush(); err != nil {
-		return nil, errors.Wrap(err, "generate flush vm storage map")
-	}
-
-	// By default no receipts/messages is serialized as the zero length
-	// slice, not the nil slice.
-	receipts := []*types.MessageReceipt{}
-	for _, r := range res.Results {
-		receipts = append(receipts, r.Receipt)
-	}
-
-	// split mined messages into secp and bls
-	minedSecpMessages, minedBLSMessages := divideMessages(res.SuccessfulMessages)
-
-	// create an aggregage signature for messages
-	unwrappedBLSMessages, blsAggregateSig, err := aggregateBLS(minedBLSMessages)
+	beaconEntries, err := collectBeaconEntries(ctx, w.beaconSource, baseHeight, blockHeight)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not aggregate bls messages")
+		return nil, errors.Wrap(err, "get beacon entries")
 	}
+	next.BeaconEntries = beaconEntries
 
-	// Persist messages to ipld storage
-	txMeta, err := w.messageStore.StoreMessages(ctx, minedSecpMessages, unwrappedBLSMessages)
-	if err != nil {
-		return nil, errors.Wrap(err, "error persisting messages")
-	}
-	rcptsCid, err := w.messageStore.StoreReceipts(ctx, receipts)
-	if err != nil {
-		return nil, errors.Wrap(err, "error persisting receipts")
+	if w.Timestamper != nil {
+		next.Timestamp = types.Uint64(w.Timestamper(baseTipSet, blockHeight))
 	}
 
-	next := &block.Block{
-		Miner:           w.minerAddr,
-		Height:          types.Uint64(blockHeight),
-		Messages:        txMeta,
-		MessageReceipts: rcptsCid,
-		Parents:         baseTipSet.Key(),
-		ParentWeight:    types.Uint64(weight),
-		ElectionProof:   electionProof,
-		StateRoot:       newStateTreeCid,
-		Tickets:         tickets,
-		Timestamp:       types.Uint64(w.clock.Now().Unix()),
-		BLSAggregateSig: blsAggregateSig,
-	}
 	workerAddr, err := w.api.MinerGetWorkerAddress(ctx, w.minerAddr, baseTipSet.Key())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read workerAddr during block generation")
 	}
-	next.BlockSig, err = w.workerSigner.SignBytes(next.SignatureData(), workerAddr)
-	if err != nil {
+	// The consensus rules also own signing: ExpectedConsensus signs with
+	// workerAddr's real key, while LocalDev signs with its fixed devnet key
+	// regardless of workerAddr.
+	if err := w.Consensus.SignBlock(ctx, next, workerAddr); err != nil {
 		return nil, errors.Wrap(err, "failed to sign block")
 	}
 
-	for i, msg := range res.PermanentFailures {
-		// We will not be able to apply this message in the future because the error was permanent.
-		// Therefore, we will remove it from the MessagePool now.
-		// There might be better places to do this, such as wherever successful messages are removed
-		// from the pool, or by posting the failure to an event bus to be handled async.
-		log.Infof("permanent ApplyMessage failure, [%s] (%s)", msg, res.PermanentErrors[i])
-		mc, err := msg.Cid()
-		if err == nil {
-			w.messageSource.Remove(mc)
-		} else {
-			log.Warnf("failed to get CID from message", err)
-		}
-	}
+	return next, nil
+}
 
-	for i, msg := range res.TemporaryFailures {
-		// We might be able to apply this message in the future because the error was temporary.
-		// Therefore, we will leave it in the MessagePool for now.
+// SelectMessages greedily chooses messages to include in a block from
+// pending, in the priority order established by MessageQueue, until either
+// the next message would push the cumulative GasLimit of the selected
+// messages past blockGasLimit or maxMessages messages have been selected.
+//
+// A message whose own GasLimit exceeds blockGasLimit can never fit in any
+// block, no matter how empty; such messages are returned separately as
+// oversized so the caller can drop them from the pool for good. Every other
+// message left out of this block is simply not selected, and remains in the
+// pool untouched for a future one.
+//
+// Once a sender's message is deemed oversized, every later message from
+// that same sender is skipped too: its lower-nonce predecessor is about to
+// be dropped from the pool for good, so it could never apply regardless of
+// gas, and leaving it selected would just turn it into a permanent
+// nonce-gap failure instead.
+func SelectMessages(pending []*types.SignedMessage, blockGasLimit types.GasUnits, maxMessages int) (selected []*types.SignedMessage, oversized []*types.SignedMessage) {
+	mq := NewMessageQueue(pending)
 
-		log.Infof("temporary ApplyMessage failure, [%s] (%s)", msg, res.TemporaryErrors[i])
+	gasUsed := types.NewGasUnits(0)
+	cutOff := map[string]bool{}
+	for _, msg := range mq.Drain() {
+		from := msg.Message.From.String()
+		if cutOff[from] {
+			continue
+		}
+		if msg.Message.GasLimit > blockGasLimit {
+			oversized = append(oversized, msg)
+			cutOff[from] = true
+			continue
+		}
+		if len(selected) >= maxMessages {
+			break
+		}
+		if gasUsed+msg.Message.GasLimit > blockGasLimit {
+			break
+		}
+		gasUsed += msg.Message.GasLimit
+		selected = append(selected, msg)
 	}
-
-	return next, nil
+	return selected, oversized
 }
 
-func aggregateBLS(blsMessages []*types.SignedMessage) ([]*types.UnsignedMessage, types.Signature, error) {
-	sigs := []bls.Signature{}
-	unwrappedMsgs := []*types.UnsignedMessage{}
-	for _, msg := range blsMessages {
-		// unwrap messages
-		unwrappedMsgs = append(unwrappedMsgs, &msg.Message)
-		sig := msg.Signature
-
-		// store message signature as bls signature
-		blsSig := bls.Signature{}
-		copy(blsSig[:], sig)
-		sigs = append(sigs, blsSig)
+// sortMessages orders messages by (from, nonce, cid) in place, so that a
+// block built from the same pending set always applies its messages in the
+// same order, regardless of the order MessageQueue happened to drain them
+// in. This is part of what makes GenerateWithSeed's output byte-reproducible.
+func sortMessages(messages []*types.SignedMessage) {
+	type keyed struct {
+		msg *types.SignedMessage
+		cid string
+	}
+
+	keys := make([]keyed, len(messages))
+	for i, msg := range messages {
+		c, err := msg.Cid()
+		if err != nil {
+			log.Warnf("failed to get CID from message during sort", err)
+		}
+		keys[i] = keyed{msg: msg, cid: c.String()}
 	}
-	blsAggregateSig := bls.Aggregate(sigs)
-	if blsAggregateSig == nil {
-		return []*types.UnsignedMessage{}, types.Signature{}, errors.New("could not aggregate signatures")
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i].msg.Message, keys[j].msg.Message
+		if a.From != b.From {
+			return a.From.String() < b.From.String()
+		}
+		if a.Nonce != b.Nonce {
+			return a.Nonce < b.Nonce
+		}
+		return keys[i].cid < keys[j].cid
+	})
+
+	for i, k := range keys {
+		messages[i] = k.msg
 	}
-	return unwrappedMsgs, blsAggregateSig[:], nil
 }
 
 func divideMessages(messages []*types.SignedMessage) ([]*types.SignedMessage, []*types.SignedMessage) {
@@ -186,4 +216,4 @@ func divideMessages(messages []*types.SignedMessage) ([]*types.SignedMessage, []
 		}
 	}
 	return secpMessages, blsMessages
-}
\ No newline at end of file
+}
@@ -0,0 +1,199 @@
+package mining
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestSelectMessagesRespectsBlockGasLimit(t *testing.T) {
+	tf.UnitTest(t)
+
+	alice := newTestAddress(t, "alice")
+	pending := []*types.SignedMessage{
+		newTestMessage(t, alice, 0, 100),
+		newTestMessage(t, alice, 1, 100),
+		newTestMessage(t, alice, 2, 100),
+	}
+
+	selected, oversized := SelectMessages(pending, types.NewGasUnits(250), 10)
+
+	assert.Empty(t, oversized)
+	require.Len(t, selected, 2)
+	assert.Equal(t, uint64(0), uint64(selected[0].Message.Nonce))
+	assert.Equal(t, uint64(1), uint64(selected[1].Message.Nonce))
+}
+
+func TestSelectMessagesRespectsMaxBlockMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	alice := newTestAddress(t, "alice")
+	pending := []*types.SignedMessage{
+		newTestMessage(t, alice, 0, 1),
+		newTestMessage(t, alice, 1, 1),
+		newTestMessage(t, alice, 2, 1),
+	}
+
+	selected, oversized := SelectMessages(pending, types.NewGasUnits(1000), 2)
+
+	assert.Empty(t, oversized)
+	assert.Len(t, selected, 2)
+}
+
+func TestSelectMessagesDropsOversizedMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	alice := newTestAddress(t, "alice")
+	bob := newTestAddress(t, "bob")
+	pending := []*types.SignedMessage{
+		newTestMessage(t, alice, 0, 500), // individually exceeds the block gas limit
+		newTestMessage(t, bob, 0, 100),
+	}
+
+	selected, oversized := SelectMessages(pending, types.NewGasUnits(250), 10)
+
+	require.Len(t, oversized, 1)
+	assert.Equal(t, alice, oversized[0].Message.From)
+	require.Len(t, selected, 1)
+	assert.Equal(t, bob, selected[0].Message.From)
+}
+
+func TestSelectMessagesLeavesNonceGapInPool(t *testing.T) {
+	tf.UnitTest(t)
+
+	alice := newTestAddress(t, "alice")
+	bob := newTestAddress(t, "bob")
+	// alice's nonce 1 message is missing: her nonce 2 message can't be
+	// drained, but bob's messages are unaffected.
+	pending := []*types.SignedMessage{
+		newTestMessage(t, alice, 0, 100),
+		newTestMessage(t, alice, 2, 100),
+		newTestMessage(t, bob, 0, 100),
+	}
+
+	selected, oversized := SelectMessages(pending, types.NewGasUnits(1000), 10)
+
+	assert.Empty(t, oversized)
+	require.Len(t, selected, 2)
+	for _, msg := range selected {
+		assert.NotEqual(t, uint64(2), uint64(msg.Message.Nonce), "nonce-gapped message should be left in the pool")
+	}
+}
+
+func TestSelectMessagesDropsRestOfSenderAfterOversizedMessage(t *testing.T) {
+	tf.UnitTest(t)
+
+	alice := newTestAddress(t, "alice")
+	bob := newTestAddress(t, "bob")
+	pending := []*types.SignedMessage{
+		newTestMessage(t, alice, 0, 500), // individually exceeds the block gas limit
+		newTestMessage(t, alice, 1, 100), // can never apply once nonce 0 is dropped from the pool
+		newTestMessage(t, bob, 0, 100),
+	}
+
+	selected, oversized := SelectMessages(pending, types.NewGasUnits(250), 10)
+
+	require.Len(t, oversized, 1)
+	assert.Equal(t, alice, oversized[0].Message.From)
+	assert.Equal(t, uint64(0), uint64(oversized[0].Message.Nonce))
+
+	require.Len(t, selected, 1)
+	assert.Equal(t, bob, selected[0].Message.From)
+	for _, msg := range selected {
+		assert.NotEqual(t, alice, msg.Message.From, "alice's nonce 1 can never apply once nonce 0 is dropped for good")
+	}
+}
+
+func TestSelectMessagesPreservesOrderWithinEachPartition(t *testing.T) {
+	tf.UnitTest(t)
+
+	secpSender := newTestAddress(t, "secp")
+	// A BLS address payload is a 48-byte public key.
+	blsSender, err := address.NewBLSAddress(make([]byte, 48))
+	require.NoError(t, err)
+
+	pending := []*types.SignedMessage{
+		newTestMessage(t, secpSender, 0, 1),
+		newTestMessage(t, blsSender, 0, 1),
+		newTestMessage(t, secpSender, 1, 1),
+		newTestMessage(t, blsSender, 1, 1),
+	}
+
+	selected, oversized := SelectMessages(pending, types.NewGasUnits(1000), 10)
+	require.Empty(t, oversized)
+
+	secpMessages, blsMessages := divideMessages(selected)
+
+	require.Len(t, secpMessages, 2)
+	assert.Equal(t, uint64(0), uint64(secpMessages[0].Message.Nonce))
+	assert.Equal(t, uint64(1), uint64(secpMessages[1].Message.Nonce))
+
+	require.Len(t, blsMessages, 2)
+	assert.Equal(t, uint64(0), uint64(blsMessages[0].Message.Nonce))
+	assert.Equal(t, uint64(1), uint64(blsMessages[1].Message.Nonce))
+}
+
+func TestSortMessagesOrdersByFromThenNonce(t *testing.T) {
+	tf.UnitTest(t)
+
+	alice, err := address.NewSecp256k1Address([]byte("sort-messages-test-address-alice"))
+	require.NoError(t, err)
+	bob, err := address.NewSecp256k1Address([]byte("sort-messages-test-address-bob"))
+	require.NoError(t, err)
+
+	messages := []*types.SignedMessage{
+		newTestMessage(t, bob, 0, 1),
+		newTestMessage(t, alice, 1, 1),
+		newTestMessage(t, alice, 0, 1),
+	}
+
+	sortMessages(messages)
+
+	require.Len(t, messages, 3)
+	assert.Equal(t, alice, messages[0].Message.From)
+	assert.Equal(t, uint64(0), uint64(messages[0].Message.Nonce))
+	assert.Equal(t, alice, messages[1].Message.From)
+	assert.Equal(t, uint64(1), uint64(messages[1].Message.Nonce))
+	assert.Equal(t, bob, messages[2].Message.From)
+}
+
+func TestSortMessagesIsStableAcrossRuns(t *testing.T) {
+	tf.UnitTest(t)
+
+	alice, err := address.NewSecp256k1Address([]byte("sort-messages-test-address-alice"))
+	require.NoError(t, err)
+
+	first := []*types.SignedMessage{
+		newTestMessage(t, alice, 2, 1),
+		newTestMessage(t, alice, 0, 1),
+		newTestMessage(t, alice, 1, 1),
+	}
+	second := []*types.SignedMessage{first[2], first[0], first[1]}
+
+	sortMessages(first)
+	sortMessages(second)
+
+	for i := range first {
+		assert.Equal(t, first[i].Message.Nonce, second[i].Message.Nonce, "sort order must not depend on input order")
+	}
+}
+
+func newTestAddress(t *testing.T, seed string) address.Address {
+	t.Helper()
+	addr, err := address.NewSecp256k1Address([]byte("select-messages-test-address-" + seed))
+	require.NoError(t, err)
+	return addr
+}
+
+func newTestMessage(t *testing.T, from address.Address, nonce uint64, gasLimit uint64) *types.SignedMessage {
+	t.Helper()
+	msg := types.NewUnsignedMessage(from, address.Undef)
+	msg.Nonce = types.Uint64(nonce)
+	msg.GasLimit = types.NewGasUnits(gasLimit)
+	return &types.SignedMessage{Message: *msg}
+}
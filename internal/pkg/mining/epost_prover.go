@@ -0,0 +1,23 @@
+package mining
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+)
+
+// SectorInfo describes one of a miner's committed sectors, the unit an
+// Election PoSt candidate is generated against.
+type SectorInfo struct {
+	SectorID uint64
+	CommR    []byte
+}
+
+// EPostProver computes Election PoSt proofs. Implementations typically
+// delegate to the sector builder's SNARK proving, which is slow enough that
+// it must run out-of-band from block generation rather than block it.
+type EPostProver interface {
+	// ComputeProof generates an EPostProof over sectors, challenged with
+	// challengeSeed.
+	ComputeProof(ctx context.Context, sectorInfo []SectorInfo, challengeSeed []byte) (block.EPostProof, error)
+}
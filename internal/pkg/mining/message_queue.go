@@ -0,0 +1,62 @@
+package mining
+
+import (
+	"sort"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// MessageQueue orders a pool's pending messages for selection into a block:
+// grouped by sender, each sender's own messages ordered by nonce, so a
+// block never ends up applying a message before an earlier one from the
+// same actor. Senders are drained in the order their first pending message
+// was seen.
+type MessageQueue struct {
+	bySender map[string][]*types.SignedMessage
+	senders  []string
+}
+
+// NewMessageQueue groups pending by sender, sorts each sender's messages by
+// nonce, and truncates each sender's run at its first nonce gap: a message
+// whose predecessor isn't in the pool can never apply before that
+// predecessor arrives, so it isn't eligible for selection yet.
+func NewMessageQueue(pending []*types.SignedMessage) *MessageQueue {
+	q := &MessageQueue{bySender: map[string][]*types.SignedMessage{}}
+	for _, msg := range pending {
+		from := msg.Message.From.String()
+		if _, ok := q.bySender[from]; !ok {
+			q.senders = append(q.senders, from)
+		}
+		q.bySender[from] = append(q.bySender[from], msg)
+	}
+	for _, from := range q.senders {
+		msgs := q.bySender[from]
+		sort.Slice(msgs, func(i, j int) bool {
+			return msgs[i].Message.Nonce < msgs[j].Message.Nonce
+		})
+		q.bySender[from] = contiguousPrefix(msgs)
+	}
+	return q
+}
+
+// contiguousPrefix returns the leading run of msgs, already sorted by
+// nonce, whose nonces increase one at a time. The first gap ends the run:
+// everything after it is left for a future block, once the gap is filled.
+func contiguousPrefix(msgs []*types.SignedMessage) []*types.SignedMessage {
+	for i := 1; i < len(msgs); i++ {
+		if msgs[i].Message.Nonce != msgs[i-1].Message.Nonce+1 {
+			return msgs[:i]
+		}
+	}
+	return msgs
+}
+
+// Drain returns every message in q, in priority order: one sender's full
+// nonce-ordered run at a time, senders in first-seen order.
+func (q *MessageQueue) Drain() []*types.SignedMessage {
+	out := make([]*types.SignedMessage, 0, len(q.senders))
+	for _, from := range q.senders {
+		out = append(out, q.bySender[from]...)
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package mining
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/beacon"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus/iface"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+var log = logging.Logger("mining")
+
+// MessageSource is the pool of messages available for a block to include:
+// DefaultWorker selects from Pending and, for messages that can never
+// apply, calls Remove so they don't sit in the pool forever.
+type MessageSource interface {
+	Pending() []*types.SignedMessage
+	Remove(c cid.Cid)
+}
+
+// WorkerAPI resolves a miner actor's current worker address, the key
+// blocks are actually signed with.
+type WorkerAPI interface {
+	MinerGetWorkerAddress(ctx context.Context, minerAddr address.Address, baseKey block.TipSetKey) (address.Address, error)
+}
+
+// DefaultWorker generates blocks on top of a base tipset: selecting
+// messages from the pool, delegating the state transition to a Consensus
+// implementation, and attaching the mining-specific parts of the header
+// (tickets, Election PoSt proof, beacon entries, signature).
+type DefaultWorker struct {
+	// Consensus owns the state transition and signing rules a produced
+	// block must satisfy; ExpectedConsensus in normal operation, LocalDev
+	// for single-miner devnets.
+	Consensus iface.Consensus
+
+	// BlockGasLimit bounds the cumulative GasLimit of messages selected
+	// into a block.
+	BlockGasLimit types.GasUnits
+	// MaxBlockMessages bounds the number of messages selected into a block,
+	// independent of BlockGasLimit.
+	MaxBlockMessages int
+
+	// Timestamper, when set, replaces the wall clock as the source of a
+	// generated block's Timestamp. Nil in normal mining.
+	Timestamper Timestamper
+
+	// EPostProver computes Election PoSt proofs out-of-band from block
+	// generation, since SNARK proving is too slow to run inline with
+	// Generate.
+	EPostProver EPostProver
+
+	minerAddr     address.Address
+	messageSource MessageSource
+	beaconSource  beacon.RandomnessSource
+	api           WorkerAPI
+}
+
+// NewDefaultWorker returns a DefaultWorker producing blocks for minerAddr
+// under consensus, selecting messages from messageSource, drawing beacon
+// entries from beaconSource, and resolving the current worker key through
+// api.
+func NewDefaultWorker(
+	consensus iface.Consensus,
+	messageSource MessageSource,
+	beaconSource beacon.RandomnessSource,
+	api WorkerAPI,
+	minerAddr address.Address,
+	blockGasLimit types.GasUnits,
+	maxBlockMessages int,
+) *DefaultWorker {
+	return &DefaultWorker{
+		Consensus:        consensus,
+		BlockGasLimit:    blockGasLimit,
+		MaxBlockMessages: maxBlockMessages,
+		minerAddr:        minerAddr,
+		messageSource:    messageSource,
+		beaconSource:     beaconSource,
+		api:              api,
+	}
+}
+
+// GenerateEPoStProof computes the Election PoSt proof for sectorInfo
+// challenged with challengeSeed, via w.EPostProver. A mining loop must call
+// this ahead of time and pass the result into Generate as eproof; Generate
+// itself never calls w.EPostProver, since SNARK proving is too slow to run
+// inline with message selection and the state transition.
+func (w *DefaultWorker) GenerateEPoStProof(ctx context.Context, sectorInfo []SectorInfo, challengeSeed []byte) (block.EPostProof, error) {
+	return w.EPostProver.ComputeProof(ctx, sectorInfo, challengeSeed)
+}
@@ -0,0 +1,47 @@
+package mining
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+// fakeEPostProver returns a fixed proof regardless of the sectors or seed
+// it's challenged with, so DefaultWorker.GenerateEPoStProof's wiring can be
+// tested without a real sector builder.
+type fakeEPostProver struct {
+	proof block.EPostProof
+	err   error
+}
+
+func (p *fakeEPostProver) ComputeProof(ctx context.Context, sectorInfo []SectorInfo, challengeSeed []byte) (block.EPostProof, error) {
+	return p.proof, p.err
+}
+
+func TestDefaultWorkerGenerateEPoStProofDelegatesToProver(t *testing.T) {
+	tf.UnitTest(t)
+
+	want := block.EPostProof{PostRand: []byte("challenge-seed")}
+	w := &DefaultWorker{EPostProver: &fakeEPostProver{proof: want}}
+
+	sectors := []SectorInfo{{SectorID: 1}}
+	got, err := w.GenerateEPoStProof(context.Background(), sectors, want.PostRand)
+
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestDefaultWorkerGenerateEPoStProofPropagatesError(t *testing.T) {
+	tf.UnitTest(t)
+
+	w := &DefaultWorker{EPostProver: &fakeEPostProver{err: assert.AnError}}
+
+	_, err := w.GenerateEPoStProof(context.Background(), nil, nil)
+
+	assert.Equal(t, assert.AnError, err)
+}